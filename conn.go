@@ -0,0 +1,624 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrConnClosed is returned by Conn.Call when the underlying connection is closed while the call is still outstanding.
+var ErrConnClosed = errors.New("jsonrpc: connection closed")
+
+// ErrUnknownResponseID is reported on Conn's Errs channel when a peer sends a response whose ID doesn't match any
+// outstanding Call, e.g. because the peer echoed back a bogus ID or sent a duplicate response for one it already
+// answered. Such responses are otherwise indistinguishable from a slow peer and would be silently dropped.
+var ErrUnknownResponseID = errors.New("jsonrpc: received a response with an unknown or duplicate id")
+
+// Stream is the transport a Conn reads messages from and writes messages to. Each ReadMessage/WriteMessage call
+// carries one JSON-RPC message or batch, however the underlying transport happens to frame it - a length-prefixed
+// TCP stream, an HTTP request/response body, or a single WebSocket frame.
+type Stream interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage([]byte) error
+	Close() error
+}
+
+// HandlerFunc handles an inbound JSON-RPC request and returns either a result to be sent back to the peer, or a ResError describing why the request could not be handled.
+// ctx is cancelled if the peer sends a "$/cancelRequest" notification for req.ID before the handler returns, or if the connection is closed; handlers that do non-trivial work should watch ctx.Done().
+type HandlerFunc func(ctx context.Context, req *Request) (result interface{}, err *ResError)
+
+// Conn is a bidirectional JSON-RPC 2.0 peer connection. Unlike the old Client, which could only issue requests and read
+// whatever came back, a Conn owns a single background read loop and can be used symmetrically on both dialed client
+// connections and accepted server connections: either side may call out with Call/Notify and both sides may serve
+// inbound requests registered with Handle.
+type Conn struct {
+	stream Stream
+
+	mu        sync.Mutex
+	pending   map[ID]chan *message
+	handling  map[ID]context.CancelFunc
+	handlers  map[string]HandlerFunc
+	subs      map[string]*Subscription
+	cancelled map[ID]struct{}
+
+	subscriptions map[string]context.CancelFunc
+
+	errs   chan error
+	closed chan struct{}
+}
+
+// newConn wraps an already established Stream and starts its read loop.
+func newConn(s Stream) *Conn {
+	conn := &Conn{
+		stream:        s,
+		pending:       make(map[ID]chan *message),
+		handling:      make(map[ID]context.CancelFunc),
+		handlers:      make(map[string]HandlerFunc),
+		subs:          make(map[string]*Subscription),
+		cancelled:     make(map[ID]struct{}),
+		subscriptions: make(map[string]context.CancelFunc),
+		errs:          make(chan error, 16),
+		closed:        make(chan struct{}),
+	}
+
+	go conn.readLoop()
+	return conn
+}
+
+// Handle registers handler to serve inbound requests for method. Handlers are invoked from the connection's read loop
+// goroutine, each in its own goroutine; only one handler may be registered per method.
+func (c *Conn) Handle(method string, handler HandlerFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[method] = handler
+}
+
+// defaultCallTimeout bounds Call, the non-context convenience wrapper around CallContext, so a peer that never
+// responds can't block it forever.
+const defaultCallTimeout = 30 * time.Second
+
+// Call issues a JSON-RPC request for method with params and blocks until a matching response arrives, the connection
+// is closed, or defaultCallTimeout elapses. It is a convenience wrapper around CallContext for callers that don't
+// need to plumb a context.Context through to control cancellation themselves.
+func (c *Conn) Call(method string, params interface{}, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultCallTimeout)
+	defer cancel()
+	return c.CallContext(ctx, method, params, result)
+}
+
+// CallContext issues a JSON-RPC request for method with params and blocks until a matching response arrives, the
+// connection is closed, or ctx is done. If ctx is cancelled or its deadline expires before a response arrives,
+// CallContext sends a "$/cancelRequest" notification carrying the request ID so the peer can abort any in-flight
+// handler via the stored context.CancelFunc, and returns ctx.Err(). On success the response result, if any, is
+// unmarshalled into result.
+func (c *Conn) CallContext(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := nextID()
+
+	ch := make(chan *message, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.writeMsg(Request{ID: id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			resErr := &ResError{Code: msg.Error.Code, Message: msg.Error.Message}
+			if msg.Error.Data != nil {
+				if err := json.Unmarshal(msg.Error.Data, &resErr.Data); err != nil {
+					return err
+				}
+			}
+			return resErr
+		}
+
+		if result != nil && msg.Result != nil {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		c.Notify(context.Background(), "$/cancelRequest", cancelRequestParams{ID: id})
+		c.markCancelled(id)
+		return ctx.Err()
+	case <-c.closed:
+		return ErrConnClosed
+	}
+}
+
+// cancelRequestParams is the params shape of a "$/cancelRequest" notification, carrying the ID of the request to
+// cancel as an ID rather than a bare string so a numeric id and a string id with the same digits (wire 7 vs "7")
+// aren't conflated on the receiving end's handling lookup.
+type cancelRequestParams struct {
+	ID ID `json:"id"`
+}
+
+// cancelledGrace is how long dispatchResponse tolerates a response for an ID whose Call already gave up, before
+// treating it as genuinely unknown. It only needs to outlast the "$/cancelRequest" round trip: the peer's own
+// response to the original request, sent before or just after it observes the cancellation, racing back in.
+const cancelledGrace = 30 * time.Second
+
+// markCancelled remembers id as recently cancelled for cancelledGrace, so a response that was already in flight when
+// Call gave up on it is not reported on Errs as ErrUnknownResponseID.
+func (c *Conn) markCancelled(id ID) {
+	c.mu.Lock()
+	c.cancelled[id] = struct{}{}
+	c.mu.Unlock()
+
+	time.AfterFunc(cancelledGrace, func() {
+		c.mu.Lock()
+		delete(c.cancelled, id)
+		c.mu.Unlock()
+	})
+}
+
+// idCounter generates the numeric IDs used to correlate outbound Call requests with their responses.
+var idCounter int64
+
+// nextID returns the next numeric request ID, unique for the lifetime of the process.
+func nextID() ID {
+	return NewNumID(atomic.AddInt64(&idCounter, 1))
+}
+
+// Errs returns a channel on which Conn reports protocol-level problems noticed while reading, such as
+// ErrUnknownResponseID, that aren't tied to a specific pending Call and so can't simply be returned from one.
+// The channel is closed once the connection's read loop exits. Errors are dropped rather than block the read loop
+// if nothing is draining the channel.
+func (c *Conn) Errs() <-chan error {
+	return c.errs
+}
+
+// reportErr delivers err on the Errs channel without blocking if nobody is reading from it.
+func (c *Conn) reportErr(err error) {
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+// Notify sends a JSON-RPC notification for method with params. Notifications do not receive a response.
+func (c *Conn) Notify(ctx context.Context, method string, params interface{}) error {
+	return c.writeMsg(Notification{Method: method, Params: params})
+}
+
+// WriteBatch writes multiple JSON-RPC messages (e.g. Request and Notification values) to the connection as a single
+// top-level JSON array, per the batch request form defined by the 2.0 spec.
+func (c *Conn) WriteBatch(msgs ...interface{}) error {
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return err
+	}
+
+	return c.stream.WriteMessage(data)
+}
+
+// ReadBatch is WriteBatch's read-side counterpart: it registers ids with the read loop's correlation machinery, the
+// same way Call registers a single one, and blocks until a response for every one of them has arrived, the
+// connection is closed, or ctx is done. Responses are matched by ID regardless of whether the peer actually sends
+// them back as one batch frame or as separate messages, and are returned keyed by ID. If ctx is done before every id
+// has a response, ReadBatch marks each still-outstanding id as recently cancelled - the same grace window Call gives
+// a single request - so the peer's late responses for them are tolerated rather than reported as
+// ErrUnknownResponseID; the responses collected up to that point are still returned alongside the error.
+func (c *Conn) ReadBatch(ctx context.Context, ids []ID) (map[ID]*Response, error) {
+	chans := make(map[ID]chan *message, len(ids))
+	c.mu.Lock()
+	for _, id := range ids {
+		ch := make(chan *message, 1)
+		chans[id] = ch
+		c.pending[id] = ch
+	}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		for id := range chans {
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+
+	out := make(map[ID]*Response, len(ids))
+	for id, ch := range chans {
+		select {
+		case msg := <-ch:
+			resp, err := responseFromMessage(msg)
+			if err != nil {
+				c.markRemainingCancelled(chans, out)
+				return out, err
+			}
+			out[id] = resp
+		case <-ctx.Done():
+			c.markRemainingCancelled(chans, out)
+			return out, ctx.Err()
+		case <-c.closed:
+			return out, ErrConnClosed
+		}
+	}
+	return out, nil
+}
+
+// markRemainingCancelled marks every id in chans that hasn't yet produced a response in out as recently cancelled,
+// mirroring CallContext's ctx.Done() handling for ReadBatch's wait-on-many-ids case.
+func (c *Conn) markRemainingCancelled(chans map[ID]chan *message, out map[ID]*Response) {
+	for id := range chans {
+		if _, done := out[id]; !done {
+			c.markCancelled(id)
+		}
+	}
+}
+
+// responseFromMessage decodes a read-side message into the public Response shape, undoing the raw-bytes deferral
+// message uses to sniff requests/notifications/responses apart before the caller's target types are known.
+func responseFromMessage(msg *message) (*Response, error) {
+	resp := &Response{}
+	if err := resp.ID.UnmarshalJSON(msg.ID); err != nil {
+		return nil, err
+	}
+
+	if msg.Error != nil {
+		resp.Error = &ResError{Code: msg.Error.Code, Message: msg.Error.Message}
+		if msg.Error.Data != nil {
+			if err := json.Unmarshal(msg.Error.Data, &resp.Error.Data); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+
+	if msg.Result != nil {
+		if err := json.Unmarshal(msg.Result, &resp.Result); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// BatchCall describes one call to make as part of a CallBatch request. Method and Params are set by the caller
+// before calling CallBatch; Result, if non-nil, receives that call's decoded result once CallBatch returns, and Err
+// carries whatever that one call failed with, independently of the other calls in the same batch.
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+
+	id ID
+}
+
+// CallBatch issues every call in calls as a single JSON-RPC batch request (one WriteBatch write) and blocks, via
+// ReadBatch, until a response for each has arrived, the connection closes, or ctx is done. Once every response has
+// arrived, each call's own outcome is recorded on its Result and Err fields and CallBatch returns nil. If writing the
+// batch or ReadBatch fails instead - ctx is done, the connection closes, or a response can't be decoded - CallBatch
+// returns that error and sets no call's Result or Err.
+func (c *Conn) CallBatch(ctx context.Context, calls []*BatchCall) error {
+	ids := make([]ID, len(calls))
+	msgs := make([]interface{}, len(calls))
+	for i, call := range calls {
+		call.id = nextID()
+		ids[i] = call.id
+		msgs[i] = Request{ID: call.id, Method: call.Method, Params: call.Params}
+	}
+
+	if err := c.WriteBatch(msgs...); err != nil {
+		return err
+	}
+
+	responses, err := c.ReadBatch(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, call := range calls {
+		resp, ok := responses[call.id]
+		if !ok {
+			call.Err = ErrConnClosed
+			continue
+		}
+		if resp.Error != nil {
+			call.Err = resp.Error
+			continue
+		}
+		if call.Result == nil || resp.Result == nil {
+			continue
+		}
+
+		data, err := json.Marshal(resp.Result)
+		if err != nil {
+			call.Err = err
+			continue
+		}
+		call.Err = json.Unmarshal(data, call.Result)
+	}
+	return nil
+}
+
+// writeMsg marshals and writes any JSON-RPC message to the connection.
+func (c *Conn) writeMsg(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return c.stream.WriteMessage(data)
+}
+
+// Close closes the underlying connection, which in turn aborts all in-flight inbound request handlers and unblocks
+// any outstanding Call.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	for _, cancel := range c.handling {
+		cancel()
+	}
+	c.mu.Unlock()
+
+	return c.stream.Close()
+}
+
+// readLoop reads and dispatches incoming messages until the connection errors out, e.g. because it was closed.
+func (c *Conn) readLoop() {
+	defer func() {
+		close(c.closed)
+
+		c.mu.Lock()
+		subs := c.subs
+		c.subs = nil
+		subscriptions := c.subscriptions
+		c.subscriptions = nil
+		c.mu.Unlock()
+
+		for _, s := range subs {
+			s.close(ErrConnClosed)
+		}
+		for _, cancel := range subscriptions {
+			cancel()
+		}
+
+		close(c.errs)
+	}()
+
+	for {
+		data, err := c.stream.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if isBatch(data) {
+			c.handleBatch(data)
+			continue
+		}
+
+		c.handleMessage(data, nil)
+	}
+}
+
+// isBatch reports whether a raw JSON-RPC frame is a batch (a top-level JSON array) rather than a single message.
+func isBatch(data []byte) bool {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleBatch decodes a batch frame into its individual messages, runs each concurrently, and - per spec - writes
+// back a single JSON array collecting only the responses to sub-requests (sub-notifications contribute nothing).
+func (c *Conn) handleBatch(data []byte) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	var responses []json.RawMessage
+	var wg sync.WaitGroup
+
+	collect := func(resp Response) {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		responses = append(responses, data)
+		mu.Unlock()
+	}
+
+	for _, raw := range raws {
+		raw := raw
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.handleMessage(raw, collect)
+		}()
+	}
+
+	wg.Wait()
+	if len(responses) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(responses)
+	if err != nil {
+		return
+	}
+	c.stream.WriteMessage(data)
+}
+
+// handleMessage decodes and dispatches a single JSON-RPC message. When collect is non-nil (we're inside a batch),
+// a request's response is handed to collect instead of being written to the connection immediately, and the handler
+// runs synchronously so the caller can wait for it via a sync.WaitGroup.
+func (c *Conn) handleMessage(data []byte, collect func(Response)) {
+	msg := message{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	switch {
+	case msg.ID != nil && msg.Method != "":
+		req := &Request{Method: msg.Method, Params: msg.Params}
+		if err := req.ID.UnmarshalJSON(msg.ID); err != nil {
+			return
+		}
+
+		if collect != nil {
+			collect(*c.invokeHandler(req))
+		} else {
+			c.dispatchRequest(req)
+		}
+	case msg.Method != "":
+		c.dispatchNotification(&Notification{Method: msg.Method, Params: msg.Params})
+	default:
+		c.dispatchResponse(&msg)
+	}
+}
+
+// dispatchRequest runs the handler registered for req.Method in its own goroutine and writes its response once done,
+// without blocking the read loop.
+func (c *Conn) dispatchRequest(req *Request) {
+	go func() {
+		c.writeMsg(*c.invokeHandler(req))
+	}()
+}
+
+// trackSubscription remembers cancel as the function that stops a server-side "<namespace>_subscribe" handler's
+// push goroutines for the subscription id it returned, so it can be cancelled once - when the peer unsubscribes via
+// cancelSubscription, or when the connection closes - rather than the instant the handler itself returns.
+func (c *Conn) trackSubscription(id string, cancel context.CancelFunc) {
+	c.mu.Lock()
+	if c.subscriptions != nil {
+		c.subscriptions[id] = cancel
+	}
+	c.mu.Unlock()
+}
+
+// cancelSubscription stops the push goroutines tracked under a server-side subscription id, if any, e.g. because the
+// peer just called its "<namespace>_unsubscribe" method.
+func (c *Conn) cancelSubscription(id string) {
+	c.mu.Lock()
+	cancel, ok := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	c.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// invokeHandler looks up the handler registered for req.Method, runs it to completion while tracking its
+// context.CancelFunc under req.ID so "$/cancelRequest" can abort it, and returns the Response to send back. Requests
+// for unregistered methods get a method-not-found error response without invoking anything. A "<namespace>_subscribe"
+// handler's ctx is the one exception: on success it is handed off to trackSubscription instead of being cancelled
+// here, so a pusher goroutine watching ctx.Done() keeps running for the subscription's lifetime, not just for the
+// duration of this one call; it is cancelled later, by cancelSubscription, once the peer unsubscribes or disconnects.
+func (c *Conn) invokeHandler(req *Request) *Response {
+	c.mu.Lock()
+	handler, ok := c.handlers[req.Method]
+	c.mu.Unlock()
+
+	if !ok {
+		return &Response{ID: req.ID, Error: &ResError{Code: ErrCodeMethodNotFound, Message: "Method not found"}}
+	}
+
+	namespace, isSubscribe := subscribeNamespace(req.Method)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if isSubscribe {
+		ctx = context.WithValue(ctx, notifierKey{}, &Notifier{conn: c, namespace: namespace})
+	}
+
+	c.mu.Lock()
+	c.handling[req.ID] = cancel
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.handling, req.ID)
+		c.mu.Unlock()
+	}()
+
+	result, rerr := handler(ctx, req)
+
+	if isSubscribe && rerr == nil {
+		if subID, ok := result.(string); ok {
+			c.trackSubscription(subID, cancel)
+		} else {
+			cancel()
+		}
+	} else {
+		cancel()
+	}
+
+	if rerr == nil {
+		if _, ok := unsubscribeNamespace(req.Method); ok {
+			if subID, ok := firstStringParam(req.Params); ok {
+				c.cancelSubscription(subID)
+			}
+		}
+	}
+
+	return &Response{ID: req.ID, Result: result, Error: rerr}
+}
+
+// dispatchNotification handles inbound notifications: the "$/cancelRequest" control notification used to cancel an
+// in-flight handler on this side, and "<namespace>_subscription" pushes destined for a Subscription created by
+// Client.Subscribe.
+func (c *Conn) dispatchNotification(not *Notification) {
+	switch {
+	case not.Method == "$/cancelRequest":
+		c.handleCancelRequest(not)
+	case strings.HasSuffix(not.Method, "_subscription"):
+		c.handleSubscriptionNotification(not)
+	}
+}
+
+func (c *Conn) handleCancelRequest(not *Notification) {
+	data, ok := not.Params.(json.RawMessage)
+	if !ok {
+		return
+	}
+
+	var p cancelRequestParams
+	if err := json.Unmarshal(data, &p); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	cancel, ok := c.handling[p.ID]
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// dispatchResponse delivers an incoming response to the channel registered for its ID by a pending Call, if any. A
+// response for an ID that Call already gave up on - because ctx was cancelled or timed out - is a normal outcome of
+// that race, not a protocol error, and is dropped silently as long as it arrives within cancelledGrace of the
+// cancellation; only a response matching neither a pending nor a recently-cancelled ID is reported.
+func (c *Conn) dispatchResponse(msg *message) {
+	var id ID
+	if err := id.UnmarshalJSON(msg.ID); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	_, cancelled := c.cancelled[id]
+	c.mu.Unlock()
+
+	if !ok {
+		if !cancelled {
+			c.reportErr(ErrUnknownResponseID)
+		}
+		return
+	}
+	ch <- msg
+}
@@ -0,0 +1,182 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// jsonrpcVersion is the protocol version tag required on every JSON-RPC 2.0 message.
+const jsonrpcVersion = "2.0"
+
+// Standard JSON-RPC 2.0 error codes, as defined by the spec. Use these for ResError.Code when the error matches one
+// of the standard conditions; application-defined errors should use codes outside the -32768 to -32000 range.
+const (
+	ErrCodeParseError     = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternalError  = -32603
+)
+
+// ID represents a JSON-RPC request/response id. Per the 2.0 spec an id is a JSON number, a JSON string, or absent
+// entirely; absence of an id (rather than an empty string id) is what marks a Request as a Notification. ID is a
+// value type so it can be compared and used as a map key.
+type ID struct {
+	num      int64
+	str      string
+	isString bool
+	isSet    bool
+}
+
+// NewNumID returns a numeric ID.
+func NewNumID(n int64) ID {
+	return ID{num: n, isSet: true}
+}
+
+// NewStringID returns a string ID.
+func NewStringID(s string) ID {
+	return ID{str: s, isString: true, isSet: true}
+}
+
+// IsSet reports whether the ID was present on the wire. An unset ID marks a Notification.
+func (id ID) IsSet() bool {
+	return id.isSet
+}
+
+// String returns the ID's string representation. A numeric id and a string id with the same digits have the same
+// String, so the pending/handling maps are keyed by the ID itself, not by this representation.
+func (id ID) String() string {
+	if id.isString {
+		return id.str
+	}
+	return strconv.FormatInt(id.num, 10)
+}
+
+// MarshalJSON encodes the ID as a bare JSON number or string, or as JSON null if it was never set.
+func (id ID) MarshalJSON() ([]byte, error) {
+	if !id.isSet {
+		return []byte("null"), nil
+	}
+	if id.isString {
+		return json.Marshal(id.str)
+	}
+	return json.Marshal(id.num)
+}
+
+// UnmarshalJSON decodes a JSON number, string, or null into an ID.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		*id = ID{}
+		return nil
+	}
+
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = NewStringID(s)
+		return nil
+	}
+
+	var n int64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = NewNumID(n)
+	return nil
+}
+
+// Request is a JSON-RPC request object, sent by a peer expecting a correlated Response in return.
+type Request struct {
+	ID     ID
+	Method string
+	Params interface{}
+}
+
+// MarshalJSON encodes the request with the required "jsonrpc":"2.0" version tag.
+func (r Request) MarshalJSON() ([]byte, error) {
+	id := r.ID
+	return json.Marshal(wireMsg{JSONRPC: jsonrpcVersion, ID: &id, Method: r.Method, Params: r.Params})
+}
+
+// Response is a JSON-RPC response object, sent in reply to a Request carrying the same ID.
+type Response struct {
+	ID     ID
+	Result interface{}
+	Error  *ResError
+}
+
+// MarshalJSON encodes the response with the required "jsonrpc":"2.0" version tag, and - per the spec - exactly one
+// of Result or Error: a successful response always carries a "result" key, even an explicit null for a method with
+// no return value, while a failed response carries "error" and omits "result" entirely.
+func (r Response) MarshalJSON() ([]byte, error) {
+	id := r.ID
+	w := wireMsg{JSONRPC: jsonrpcVersion, ID: &id, Error: r.Error}
+
+	if r.Error == nil {
+		result, err := json.Marshal(r.Result)
+		if err != nil {
+			return nil, err
+		}
+		w.Result = result
+	}
+
+	return json.Marshal(w)
+}
+
+// Notification is a JSON-RPC request object without an id, sent when no response is expected.
+type Notification struct {
+	Method string
+	Params interface{}
+}
+
+// MarshalJSON encodes the notification with the required "jsonrpc":"2.0" version tag and no "id" field, per the
+// spec's definition of a notification as a request object without an id.
+func (n Notification) MarshalJSON() ([]byte, error) {
+	return json.Marshal(wireMsg{JSONRPC: jsonrpcVersion, Method: n.Method, Params: n.Params})
+}
+
+// ResError represents a JSON-RPC response error object.
+type ResError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error implements the error interface so a ResError can be returned and compared like any other Go error.
+func (e *ResError) Error() string {
+	return e.Message
+}
+
+// wireMsg is the on-the-wire shape shared by Request, Response, and Notification; each type's MarshalJSON builds one
+// of these to avoid repeating the "jsonrpc" version tag and field-omission rules in three places.
+type wireMsg struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *ID             `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResError       `json:"error,omitempty"`
+}
+
+// message is the read-side counterpart of wireMsg, used to sniff an incoming JSON-RPC message before deciding
+// whether it is a request, a response, or a notification. ID is kept as raw JSON so its absence (a notification) can
+// be told apart from an explicit JSON null (e.g. the id on a parse-error response).
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Result  json.RawMessage `json:"result"`
+	Error   *msgError       `json:"error"`
+}
+
+// msgError mirrors ResError but keeps Data as raw JSON until the caller's target data structure is known.
+type msgError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
@@ -0,0 +1,200 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"unicode"
+
+	"github.com/neptulon/neptulon"
+)
+
+// Server accepts incoming JSON-RPC 2.0 peer connections. Each accepted connection is wrapped in a Conn so that
+// handlers registered with Conn.Handle, or with this Server's Register, can serve inbound requests from whichever
+// peer happens to dial in, and the server can just as well Call or Notify back on the same connection.
+type Server struct {
+	ln *neptulon.Listener
+
+	mu       sync.Mutex
+	handlers map[string]HandlerFunc
+}
+
+// Listen starts a JSON-RPC 2.0 server listening on the given network address with optional CA and/or a server certificate (PEM encoded X.509 cert/key).
+// Debug mode logs all raw TCP communication.
+func Listen(addr string, ca []byte, cert []byte, certKey []byte, debug bool) (*Server, error) {
+	ln, err := neptulon.Listen(addr, ca, cert, certKey, debug)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{ln: ln, handlers: make(map[string]HandlerFunc)}, nil
+}
+
+// RPCError is implemented by errors that want to control the JSON-RPC error code and data sent back to the peer,
+// rather than being mapped to a generic ErrCodeInternalError response.
+type RPCError interface {
+	error
+	ErrorCode() int
+	ErrorData() interface{}
+}
+
+// Register uses reflection to expose every exported method on receiver whose signature is one of:
+//
+//	func(ctx context.Context, args *T) (result R, err error)
+//	func(ctx context.Context, args *T) error
+//	func(ctx context.Context) (result R, err error)
+//	func(ctx context.Context) error
+//
+// as a JSON-RPC method named "<namespace>_<methodName>", with the first letter of methodName lowercased. At dispatch
+// time, Params is unmarshalled into a freshly allocated *T, accepting either positional array params (the first
+// element is unmarshalled into *T) or by-name object params; the method's return value, if any, becomes the
+// response result, and a returned error is mapped to a ResError, honoring RPCError if the error implements it.
+// Register returns an error if receiver exposes no method matching one of the above signatures.
+func (s *Server) Register(namespace string, receiver interface{}) error {
+	rv := reflect.ValueOf(receiver)
+	rt := rv.Type()
+
+	handlers := make(map[string]HandlerFunc)
+	for i := 0; i < rt.NumMethod(); i++ {
+		m := rt.Method(i)
+		handler, ok := makeHandler(rv.Method(i))
+		if !ok {
+			continue
+		}
+
+		handlers[namespace+"_"+lowerFirst(m.Name)] = handler
+	}
+
+	if len(handlers) == 0 {
+		return fmt.Errorf("jsonrpc: %T exposes no methods matching a supported handler signature", receiver)
+	}
+
+	s.mu.Lock()
+	for method, handler := range handlers {
+		s.handlers[method] = handler
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+var (
+	ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// makeHandler builds a HandlerFunc around a bound receiver method, or reports false if its signature doesn't match
+// one of the forms Register supports.
+func makeHandler(method reflect.Value) (HandlerFunc, bool) {
+	t := method.Type()
+
+	if t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != ctxType {
+		return nil, false
+	}
+	hasArgs := t.NumIn() == 2
+	if hasArgs && t.In(1).Kind() != reflect.Ptr {
+		return nil, false
+	}
+
+	if t.NumOut() < 1 || t.NumOut() > 2 || t.Out(t.NumOut()-1) != errType {
+		return nil, false
+	}
+	hasResult := t.NumOut() == 2
+
+	var argType reflect.Type
+	if hasArgs {
+		argType = t.In(1)
+	}
+
+	return func(ctx context.Context, req *Request) (interface{}, *ResError) {
+		in := []reflect.Value{reflect.ValueOf(ctx)}
+
+		if hasArgs {
+			args := reflect.New(argType.Elem())
+			raw, _ := req.Params.(json.RawMessage)
+			if err := unmarshalParams(raw, args.Interface()); err != nil {
+				return nil, &ResError{Code: ErrCodeInvalidParams, Message: err.Error()}
+			}
+			in = append(in, args)
+		}
+
+		out := method.Call(in)
+
+		resVal, errVal := reflect.Value{}, out[len(out)-1]
+		if hasResult {
+			resVal = out[0]
+		}
+
+		if !errVal.IsNil() {
+			err := errVal.Interface().(error)
+			if rerr, ok := err.(RPCError); ok {
+				return nil, &ResError{Code: rerr.ErrorCode(), Message: rerr.Error(), Data: rerr.ErrorData()}
+			}
+			return nil, &ResError{Code: ErrCodeInternalError, Message: err.Error()}
+		}
+
+		if hasResult {
+			return resVal.Interface(), nil
+		}
+		return nil, nil
+	}, true
+}
+
+// unmarshalParams decodes raw into target (a pointer), accepting either a JSON-RPC positional array params value
+// (in which case only the first element is decoded into target) or a by-name object params value.
+func unmarshalParams(raw json.RawMessage, target interface{}) error {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+
+	if trimmed[0] != '[' {
+		return json.Unmarshal(trimmed, target)
+	}
+
+	var arr []json.RawMessage
+	if err := json.Unmarshal(trimmed, &arr); err != nil {
+		return err
+	}
+	if len(arr) == 0 {
+		return nil
+	}
+	return json.Unmarshal(arr[0], target)
+}
+
+// lowerFirst lowercases the first rune of s, used to turn a Go method name into its JSON-RPC method name suffix.
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// Accept blocks until a new peer connects and returns a bidirectional Conn for it, with every handler registered via
+// Register already wired up.
+func (s *Server) Accept() (*Conn, error) {
+	c, err := s.ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newConn(&tcpStream{conn: c})
+
+	s.mu.Lock()
+	for method, handler := range s.handlers {
+		conn.Handle(method, handler)
+	}
+	s.mu.Unlock()
+
+	return conn, nil
+}
+
+// Close stops the server from accepting any further connections.
+func (s *Server) Close() error {
+	return s.ln.Close()
+}
@@ -0,0 +1,230 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// subscribeNamespace reports the namespace of a "<namespace>_subscribe" method name, modelled on go-ethereum's
+// eth_subscribe convention.
+func subscribeNamespace(method string) (string, bool) {
+	const suffix = "_subscribe"
+	if !strings.HasSuffix(method, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(method, suffix), true
+}
+
+// unsubscribeNamespace reports the namespace of a "<namespace>_unsubscribe" method name, the counterpart of
+// subscribeNamespace used by invokeHandler to notice when a server-side subscription should be torn down.
+func unsubscribeNamespace(method string) (string, bool) {
+	const suffix = "_unsubscribe"
+	if !strings.HasSuffix(method, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(method, suffix), true
+}
+
+// firstStringParam extracts a single string id from a "<namespace>_unsubscribe" request's params, accepting either
+// the positional array form Subscription.Unsubscribe sends ([]interface{}{id}) or a bare string.
+func firstStringParam(params interface{}) (string, bool) {
+	raw, ok := params.(json.RawMessage)
+	if !ok {
+		return "", false
+	}
+
+	var arr []string
+	if err := json.Unmarshal(raw, &arr); err == nil && len(arr) > 0 {
+		return arr[0], true
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, true
+	}
+	return "", false
+}
+
+// subscriptionQueueSize is how many undelivered notification results a Subscription buffers before dropping new
+// ones (reported via ErrSubscriptionOverflow) rather than stalling the connection's read loop.
+const subscriptionQueueSize = 64
+
+// ErrSubscriptionOverflow is reported on a Conn's Errs channel when a Subscription's consumer can't keep up and a
+// notification is dropped, rather than blocking the read loop indefinitely on a full or unread channel.
+var ErrSubscriptionOverflow = errors.New("jsonrpc: subscription notification dropped, consumer too slow")
+
+// Subscription represents an active server-side push subscription created by Client.Subscribe. Incoming
+// "<namespace>_subscription" notifications whose params carry this subscription's id are unmarshalled into the
+// element type of the channel passed to Subscribe and delivered on it by the subscription's own forward goroutine,
+// which is also the sole owner of that channel - it is the only thing that ever sends on it or closes it.
+type Subscription struct {
+	conn      *Conn
+	namespace string
+	id        string
+
+	chanVal  reflect.Value
+	elemType reflect.Type
+	queue    chan reflect.Value
+	done     chan struct{}
+
+	quitOnce sync.Once
+	errChan  chan error
+}
+
+// Err returns a channel that receives a single value when the subscription ends, either because Unsubscribe was
+// called (nil error) or the connection was lost (ErrConnClosed). A subscription ended by the server while the
+// connection stays open is not reported on this channel.
+func (s *Subscription) Err() <-chan error {
+	return s.errChan
+}
+
+// Unsubscribe sends a "<namespace>_unsubscribe" call for this subscription and closes its delivery channel. Safe to
+// call more than once.
+func (s *Subscription) Unsubscribe() error {
+	s.conn.mu.Lock()
+	if s.conn.subs != nil {
+		delete(s.conn.subs, s.id)
+	}
+	s.conn.mu.Unlock()
+
+	err := s.conn.Call(s.namespace+"_unsubscribe", []interface{}{s.id}, nil)
+	s.close(nil)
+	return err
+}
+
+// close delivers err on the Err channel and stops the forward goroutine, exactly once. The delivery channel itself
+// is closed by forward, not here, so a close can never race a send on it.
+func (s *Subscription) close(err error) {
+	s.quitOnce.Do(func() {
+		s.errChan <- err
+		close(s.errChan)
+		close(s.done)
+	})
+}
+
+// enqueue buffers a decoded notification result for delivery by forward. Called from Conn's read loop, it never
+// blocks: if the queue is full, the notification is dropped and ErrSubscriptionOverflow is reported on the
+// connection's Errs channel instead of stalling every other Call and response on the connection.
+func (s *Subscription) enqueue(v reflect.Value) {
+	select {
+	case s.queue <- v:
+	default:
+		s.conn.reportErr(ErrSubscriptionOverflow)
+	}
+}
+
+// forward drains the queue and delivers each result to the user's channel, blocking as needed - but on its own
+// goroutine, never on Conn's read loop. As the channel's sole owner it also closes it once done fires, so Close and
+// Send can never race each other.
+func (s *Subscription) forward() {
+	defer s.chanVal.Close()
+
+	for {
+		select {
+		case v := <-s.queue:
+			s.chanVal.Send(v)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Subscribe issues a "<namespace>_subscribe" request with args and returns a Subscription that demultiplexes
+// subsequent "<namespace>_subscription" notifications into channel, modelled on go-ethereum's
+// eth_subscribe/eth_unsubscribe/eth_subscription pattern. channel must be a writable channel; its element type is
+// used to unmarshal each notification's result payload via reflection.
+func (c *Client) Subscribe(ctx context.Context, namespace string, channel interface{}, args ...interface{}) (*Subscription, error) {
+	chanVal := reflect.ValueOf(channel)
+	if chanVal.Kind() != reflect.Chan || chanVal.Type().ChanDir()&reflect.SendDir == 0 {
+		return nil, fmt.Errorf("jsonrpc: channel argument must be a writable channel, got %T", channel)
+	}
+
+	var subID string
+	if err := c.CallContext(ctx, namespace+"_subscribe", args, &subID); err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		conn:      c.Conn,
+		namespace: namespace,
+		id:        subID,
+		chanVal:   chanVal,
+		elemType:  chanVal.Type().Elem(),
+		queue:     make(chan reflect.Value, subscriptionQueueSize),
+		done:      make(chan struct{}),
+		errChan:   make(chan error, 1),
+	}
+	go sub.forward()
+
+	c.mu.Lock()
+	if c.subs == nil {
+		c.mu.Unlock()
+		sub.close(ErrConnClosed)
+		return sub, nil
+	}
+	c.subs[subID] = sub
+	c.mu.Unlock()
+
+	return sub, nil
+}
+
+// handleSubscriptionNotification unmarshals a "<namespace>_subscription" notification's {subscription, result}
+// params and queues the result for delivery to the matching Subscription's channel.
+func (c *Conn) handleSubscriptionNotification(not *Notification) {
+	data, ok := not.Params.(json.RawMessage)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	sub, ok := c.subs[payload.Subscription]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	elem := reflect.New(sub.elemType)
+	if err := json.Unmarshal(payload.Result, elem.Interface()); err != nil {
+		return
+	}
+
+	sub.enqueue(elem.Elem())
+}
+
+// notifierKey is the context key under which a Notifier is stored for a subscribe handler.
+type notifierKey struct{}
+
+// Notifier lets a "<namespace>_subscribe" handler push "<namespace>_subscription" notifications back to the peer
+// that created the subscription, under the subscription id the handler itself assigned and returned as its result.
+type Notifier struct {
+	conn      *Conn
+	namespace string
+}
+
+// NotifierFromContext returns the Notifier the server stored in ctx for a "_subscribe" handler, or false if ctx
+// carries no Notifier (e.g. the handler was invoked for a plain request rather than a subscribe call).
+func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
+	n, ok := ctx.Value(notifierKey{}).(*Notifier)
+	return n, ok
+}
+
+// Notify pushes result to the peer as a "<namespace>_subscription" notification carrying subID.
+func (n *Notifier) Notify(subID string, result interface{}) error {
+	return n.conn.Notify(context.Background(), n.namespace+"_subscription", map[string]interface{}{
+		"subscription": subID,
+		"result":       result,
+	})
+}
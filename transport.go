@@ -0,0 +1,186 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/neptulon/neptulon"
+)
+
+// tcpStream adapts a neptulon.Conn (the original raw TCP/TLS transport) to the Stream interface.
+type tcpStream struct {
+	conn *neptulon.Conn
+}
+
+func (s *tcpStream) ReadMessage() ([]byte, error)   { return s.conn.Read() }
+func (s *tcpStream) WriteMessage(data []byte) error { return s.conn.Write(data) }
+func (s *tcpStream) Close() error                   { return s.conn.Close() }
+
+// SetReadDeadline set the read deadline for the connection in seconds.
+func (s *tcpStream) SetReadDeadline(seconds int) {
+	s.conn.SetReadDeadline(seconds)
+}
+
+// deadlineSetter is implemented by streams that support read deadlines, such as tcpStream.
+type deadlineSetter interface {
+	SetReadDeadline(seconds int)
+}
+
+// clientConfig holds the options accumulated from Option funcs passed to DialHTTP or DialWebSocket.
+type clientConfig struct {
+	httpClient *http.Client
+	header     http.Header
+}
+
+// Option configures a Client created by DialHTTP or DialWebSocket.
+type Option func(*clientConfig)
+
+// WithHTTPClient overrides the *http.Client used by DialHTTP, e.g. to set a custom Transport or timeout.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *clientConfig) { c.httpClient = client }
+}
+
+// WithHeader adds a header to every request (DialHTTP) or handshake (DialWebSocket), e.g. for authentication.
+func WithHeader(key, value string) Option {
+	return func(c *clientConfig) {
+		if c.header == nil {
+			c.header = make(http.Header)
+		}
+		c.header.Add(key, value)
+	}
+}
+
+// httpStream adapts plain HTTP POST request/response round trips to the Stream interface. Since JSON-RPC over HTTP
+// has no way for the server to push unsolicited messages, ReadMessage simply returns whatever WriteMessage's most
+// recent POST got back: the raw response body, or nothing for a notification (which the spec says gets back an
+// empty HTTP 204).
+type httpStream struct {
+	url    string
+	header http.Header
+	client *http.Client
+
+	resp   chan []byte
+	closed chan struct{}
+}
+
+func newHTTPStream(url string, cfg *clientConfig) *httpStream {
+	client := cfg.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &httpStream{
+		url:    url,
+		header: cfg.header,
+		client: client,
+		resp:   make(chan []byte, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (s *httpStream) WriteMessage(data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, vs := range s.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.resp <- body:
+		return nil
+	case <-s.closed:
+		return ErrConnClosed
+	}
+}
+
+func (s *httpStream) ReadMessage() ([]byte, error) {
+	select {
+	case body := <-s.resp:
+		return body, nil
+	case <-s.closed:
+		return nil, ErrConnClosed
+	}
+}
+
+func (s *httpStream) Close() error {
+	close(s.closed)
+	return nil
+}
+
+// websocketStream adapts a gorilla/websocket connection to the Stream interface, treating each WebSocket frame as
+// one JSON-RPC message (or batch).
+type websocketStream struct {
+	conn *websocket.Conn
+}
+
+func (s *websocketStream) ReadMessage() ([]byte, error) {
+	_, data, err := s.conn.ReadMessage()
+	return data, err
+}
+
+func (s *websocketStream) WriteMessage(data []byte) error {
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (s *websocketStream) Close() error {
+	return s.conn.Close()
+}
+
+// DialHTTP creates a new client that sends each request as an HTTP POST to url and reads the response from the
+// POST's body, per the JSON-RPC 2.0 HTTP binding: a notification gets back an empty 204 response, and a batch
+// request/response travels as a single POST whose body is a JSON array.
+func DialHTTP(url string, opts ...Option) (*Client, error) {
+	cfg := &clientConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &Client{Conn: newConn(newHTTPStream(url, cfg))}, nil
+}
+
+// DialWebSocket creates a new client over a WebSocket connection to url, treating each WebSocket frame as one
+// JSON-RPC message (or batch). origin, if non-empty, is sent as the Origin header during the handshake, as required
+// by some WebSocket servers.
+func DialWebSocket(url string, origin string, opts ...Option) (*Client, error) {
+	cfg := &clientConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	header := cfg.header
+	if header == nil {
+		header = make(http.Header)
+	}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{Conn: newConn(&websocketStream{conn: conn})}, nil
+}